@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -29,34 +31,75 @@ type accountGetter interface {
 	Get(context.Context, string) (*model.CloudAccount, error)
 }
 
+// workflowRegistry looks up a provider's WorkflowSet, as populated by each
+// provider subpackage's init() via workflows.Register. It replaces the
+// single hardcoded DigitalOcean entry NewHandler used to carry, so adding a
+// provider no longer means editing this package.
+type workflowRegistry interface {
+	Lookup(clouds.Name) (workflows.WorkflowSet, error)
+}
+
+// nodeProvisioner provisions the nodes of a cluster, returning the names it
+// created. It stays a handler-injected dependency, rather than something
+// this package constructs itself, since provisioning a node is provider
+// machinery the kube package has no business building.
 type nodeProvisioner interface {
 	ProvisionNodes(context.Context, []profile.NodeProfile, *model.Kube, *steps.Config) ([]string, error)
 }
 
+// taskRunner enqueues a task for out-of-process execution instead of
+// running it inline, so the workflows.Runner worker pool can rate-limit
+// calls to the cloud provider and guarantee only one HA replica runs it.
+// Completion is observed via AwaitCompletion rather than a callback, since
+// the replica that enqueues a task isn't necessarily the one whose worker
+// pool ends up running it. provider is threaded through so the runner's
+// worker pool can pick the right per-cloud rate limiter.
+type taskRunner interface {
+	Enqueue(ctx context.Context, t *workflows.Task, provider clouds.Name, config steps.Config) error
+	AwaitCompletion(ctx context.Context, taskID string) error
+	RegisterAdmin(router *mux.Router)
+}
+
+// releaseManager manages the lifecycle of Helm releases installed onto a
+// provisioned kube.
+type releaseManager interface {
+	Install(ctx context.Context, k *model.Kube, req ReleaseInstallRequest) (*Release, error)
+	List(ctx context.Context, kname string) ([]*Release, error)
+	Get(ctx context.Context, kname, rname string) (*Release, error)
+	Upgrade(ctx context.Context, k *model.Kube, rname string, req ReleaseUpgradeRequest) (*Release, error)
+	Uninstall(ctx context.Context, k *model.Kube, rname string, keepHistory bool) error
+	Rollback(ctx context.Context, k *model.Kube, rname string, toRevision int) (*Release, error)
+}
+
 // Handler is a http controller for a kube entity.
 type Handler struct {
 	svc             Interface
 	accountService  accountGetter
+	registry        workflowRegistry
 	nodeProvisioner nodeProvisioner
-	workflowMap     map[clouds.Name]workflows.WorkflowSet
+	releaseService  releaseManager
+	runner          taskRunner
 	repo            storage.Interface
 	getWriter       func(string) (io.WriteCloser, error)
 }
 
-// NewHandler constructs a Handler for kubes.
+// NewHandler constructs a Handler for kubes. Providers register their
+// WorkflowSet into workflows.DefaultRegistry from their own init()
+// functions, so this no longer needs to know about any provider by name.
+// Deletions are handed to the shared workflows.DefaultRunner rather than
+// run inline, so its worker pool can rate-limit and leader-elect across
+// Supergiant replicas; that same runner backs the admin-facing
+// GET /workflows/queue endpoint registered alongside this handler.
 func NewHandler(svc Interface, accountService accountGetter, provisioner nodeProvisioner, repo storage.Interface) *Handler {
 	return &Handler{
 		svc:             svc,
 		accountService:  accountService,
+		registry:        workflows.DefaultRegistry,
 		nodeProvisioner: provisioner,
-		workflowMap: map[clouds.Name]workflows.WorkflowSet{
-			clouds.DigitalOcean: {
-				DeleteCluster: workflows.DigitalOceanDeleteCluster,
-				DeleteNode:    workflows.DigitalOceanDeleteNode,
-			},
-		},
-		repo:      repo,
-		getWriter: util.GetWriter,
+		releaseService:  NewReleaseService(svc, repo),
+		runner:          workflows.DefaultRunner,
+		repo:            repo,
+		getWriter:       util.GetWriter,
 	}
 }
 
@@ -72,9 +115,19 @@ func (h *Handler) Register(r *mux.Router) {
 
 	r.HandleFunc("/kubes/{kname}/certs/{cname}", h.getCerts).Methods(http.MethodGet)
 	r.HandleFunc("/kubes/{kname}/tasks", h.getTasks).Methods(http.MethodGet)
+	r.HandleFunc("/kubes/{kname}/tasks/stream", h.streamTasks).Methods(http.MethodGet)
 
 	r.HandleFunc("/kubes/{kname}/nodes", h.addNode).Methods(http.MethodPost)
 	r.HandleFunc("/kubes/{kname}/nodes/{nodename}", h.deleteNode).Methods(http.MethodDelete)
+
+	r.HandleFunc("/kubes/{kname}/releases", h.installRelease).Methods(http.MethodPost)
+	r.HandleFunc("/kubes/{kname}/releases", h.listReleases).Methods(http.MethodGet)
+	r.HandleFunc("/kubes/{kname}/releases/{rname}", h.getRelease).Methods(http.MethodGet)
+	r.HandleFunc("/kubes/{kname}/releases/{rname}", h.upgradeRelease).Methods(http.MethodPut)
+	r.HandleFunc("/kubes/{kname}/releases/{rname}", h.uninstallRelease).Methods(http.MethodDelete)
+	r.HandleFunc("/kubes/{kname}/releases/{rname}/rollback", h.rollbackRelease).Methods(http.MethodPost)
+
+	h.runner.RegisterAdmin(r)
 }
 
 func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
@@ -151,7 +204,7 @@ func (h *Handler) getKube(w http.ResponseWriter, r *http.Request) {
 
 	kname := vars["kname"]
 
-	k, err := h.svc.Get(r.Context(), kname)
+	k, _, err := h.svc.Get(r.Context(), kname)
 	if err != nil {
 		if sgerrors.IsNotFound(err) {
 			message.SendNotFound(w, kname, err)
@@ -182,7 +235,7 @@ func (h *Handler) deleteKube(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	kname := vars["kname"]
-	k, err := h.svc.Get(r.Context(), kname)
+	k, _, err := h.svc.Get(r.Context(), kname)
 	if err != nil {
 		if sgerrors.IsNotFound(err) {
 			message.SendNotFound(w, kname, err)
@@ -204,7 +257,17 @@ func (h *Handler) deleteKube(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, err := workflows.NewTask(h.workflowMap[acc.Provider].DeleteCluster, h.repo)
+	ws, err := h.registry.Lookup(acc.Provider)
+	if err != nil {
+		if sgerrors.IsUnsupportedProvider(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	t, err := workflows.NewTask(ws.DeleteCluster, h.repo)
 
 	if err != nil {
 		if sgerrors.IsNotFound(err) {
@@ -232,18 +295,24 @@ func (h *Handler) deleteKube(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writer, err := h.getWriter(t.ID)
+	// Enqueue rather than run inline: the runner's worker pool (rate
+	// limited per provider, leader-elected across replicas) picks it up
+	// from etcd instead of racing another replica for it in-process.
+	err = h.runner.Enqueue(r.Context(), t, acc.Provider, *config)
 
 	if err != nil {
 		message.SendUnknownError(w, err)
 		return
 	}
 
-	errChan := t.Run(context.Background(), *config, writer)
-
-	go func(t *workflows.Task) {
-		err := <-errChan
-		if err != nil {
+	// AwaitCompletion polls the queue's persisted status rather than
+	// relying on a callback from whichever replica's worker pool runs the
+	// task - this replica (the one that received the request) is the one
+	// that needs to know when it's done, not necessarily the one that ran
+	// it.
+	go func(taskID string) {
+		if err := h.runner.AwaitCompletion(context.Background(), taskID); err != nil {
+			logrus.Errorf("delete kube %s caused %v", kname, err)
 			return
 		}
 
@@ -254,7 +323,7 @@ func (h *Handler) deleteKube(w http.ResponseWriter, r *http.Request) {
 		}
 
 		h.deleteClusterTasks(context.Background(), kname)
-	}(t)
+	}(t.ID)
 
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -327,10 +396,8 @@ func (h *Handler) getCerts(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) addNode(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	kname := vars["kname"]
-	k, err := h.svc.Get(r.Context(), kname)
+	k, _, err := h.svc.Get(r.Context(), kname)
 
-	// TODO(stgleb): This method contains a lot of specific stuff, implement provision node
-	// method for nodeProvisioner to do all things related to provisioning and saving cluster state
 	if sgerrors.IsNotFound(err) {
 		http.NotFound(w, r)
 		return
@@ -361,28 +428,20 @@ func (h *Handler) addNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	kubeProfile := profile.Profile{
-		Provider:        acc.Provider,
-		Region:          k.Region,
-		Arch:            k.Arch,
-		OperatingSystem: k.OperatingSystem,
-		UbuntuVersion:   k.OperatingSystemVersion,
-		DockerVersion:   k.DockerVersion,
-		K8SVersion:      k.K8SVersion,
-		HelmVersion:     k.HelmVersion,
-
-		NetworkType:    k.Networking.Type,
-		CIDR:           k.Networking.CIDR,
-		FlannelVersion: k.Networking.Version,
-
-		NodesProfiles: []profile.NodeProfile{
-			{},
-		},
-
-		RBACEnabled: k.RBACEnabled,
+	ws, err := h.registry.Lookup(acc.Provider)
+	if err != nil {
+		if sgerrors.IsUnsupportedProvider(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	config := steps.NewConfig(k.Name, "", k.AccountName, kubeProfile)
+	// The node profile is provider-specific, so it comes from the registry
+	// rather than being built ad hoc here - ws.NodeProfile is populated by
+	// acc.Provider's own init() via workflows.Register.
+	config := steps.NewConfig(k.Name, "", k.AccountName, ws.NodeProfile(k))
 
 	if len(k.Masters) != 0 {
 		config.AddMaster(util.GetRandomNode(k.Masters))
@@ -412,6 +471,14 @@ func (h *Handler) addNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ProvisionNodes returns the names of the nodes it created; round-trip
+	// them into the kube record the same way removeNodeFromKube does for a
+	// deletion, so a concurrent addNode/deleteNode on this cluster can't
+	// silently clobber this update.
+	if err := h.addNodesToKube(context.Background(), kname, tasks); err != nil {
+		logrus.Errorf("update cluster %s caused %v", kname, err)
+	}
+
 	// Respond to client side that request has been accepted
 	w.WriteHeader(http.StatusAccepted)
 	err = json.NewEncoder(w).Encode(tasks)
@@ -422,14 +489,37 @@ func (h *Handler) addNode(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// TODO(stgleb): cover with unit tests
+const defaultDrainGracePeriod = 30 * time.Second
+
+// deleteNode enqueues the node's cordon-and-drain alongside its deletion:
+// the queued steps.Config carries the node name and DrainConfig, and the
+// runner's worker pool runs DrainNode as the first thing it does with this
+// task, before tearing down the underlying VM. Running it there - rather
+// than inline on this request - keeps an unbounded, user-controlled
+// ?gracePeriod= from blocking the HTTP response, and keeps it under the
+// same leader-election/rate-limiting as the deletion itself. Set
+// ?force=true to skip PDB-respecting eviction and ?gracePeriod=<seconds> to
+// override how long it waits for the node to empty out before proceeding
+// anyway.
 func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	kname := vars["kname"]
 	nodeName := vars["nodename"]
 
-	k, err := h.svc.Get(r.Context(), kname)
+	force := r.URL.Query().Get("force") == "true"
+	gracePeriod := defaultDrainGracePeriod
+
+	if raw := r.URL.Query().Get("gracePeriod"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "gracePeriod must be an integer number of seconds", http.StatusBadRequest)
+			return
+		}
+		gracePeriod = time.Duration(seconds) * time.Second
+	}
+
+	k, _, err := h.svc.Get(r.Context(), kname)
 	if err != nil {
 		if sgerrors.IsNotFound(err) {
 			message.SendNotFound(w, kname, err)
@@ -463,7 +553,17 @@ func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	t, err := workflows.NewTask(h.workflowMap[acc.Provider].DeleteNode, h.repo)
+	ws, err := h.registry.Lookup(acc.Provider)
+	if err != nil {
+		if sgerrors.IsUnsupportedProvider(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	t, err := workflows.NewTask(ws.DeleteNode, h.repo)
 
 	if err != nil {
 		if sgerrors.IsNotFound(err) {
@@ -475,12 +575,29 @@ func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	kubeconfig, err := h.svc.GetCerts(r.Context(), kname, "admin")
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	drainConfig := steps.DrainConfig{
+		Force:       force,
+		GracePeriod: gracePeriod,
+		Kubeconfig:  kubeconfig,
+	}
+
 	config := &steps.Config{
 		ClusterName:      k.Name,
 		CloudAccountName: k.AccountName,
 		Node: node.Node{
 			Name: nodeName,
 		},
+		DrainConfig: drainConfig,
 	}
 
 	err = util.FillCloudAccountCredentials(r.Context(), acc, config)
@@ -494,34 +611,89 @@ func (h *Handler) deleteNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writer, err := h.getWriter(t.ID)
+	err = h.runner.Enqueue(r.Context(), t, acc.Provider, *config)
 
 	if err != nil {
 		message.SendUnknownError(w, err)
 		return
 	}
 
-	errChan := t.Run(context.Background(), *config, writer)
-
-	// Update cluster state when deletion completes
-	go func() {
-		err := <-errChan
-
-		if err != nil {
+	// Update cluster state once the runner's worker pool completes
+	// deletion - polled via AwaitCompletion rather than a callback, since
+	// the replica draining the queue may not be this one.
+	go func(taskID string) {
+		if err := h.runner.AwaitCompletion(context.Background(), taskID); err != nil {
 			logrus.Errorf("delete node %s from cluster %s caused %v", nodeName, kname, err)
 		}
 
-		// Delete node from cluster object
+		if err := h.removeNodeFromKube(context.Background(), kname, nodeName); err != nil {
+			logrus.Errorf("update cluster %s caused %v", kname, err)
+		}
+	}(t.ID)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+const (
+	updateKubeMaxRetries  = 5
+	updateKubeRetryJitter = 50 * time.Millisecond
+)
+
+// removeNodeFromKube drops nodeName from the kube's node map, retrying on a
+// resource-version conflict so a concurrent addNode/deleteNode on the same
+// cluster can't silently clobber this update.
+func (h *Handler) removeNodeFromKube(ctx context.Context, kname, nodeName string) error {
+	return h.updateKubeWithRetry(ctx, kname, func(k *model.Kube) {
 		delete(k.Nodes, nodeName)
-		// Save cluster object to etcd
-		logrus.Infof("delete node %s from cluster %s", nodeName, kname)
-		err = h.svc.Create(context.Background(), k)
+	})
+}
+
+// addNodesToKube adds names to the kube's node map, retrying on a
+// resource-version conflict for the same reason removeNodeFromKube does.
+func (h *Handler) addNodesToKube(ctx context.Context, kname string, names []string) error {
+	return h.updateKubeWithRetry(ctx, kname, func(k *model.Kube) {
+		if k.Nodes == nil {
+			k.Nodes = map[string]*node.Node{}
+		}
+		for _, name := range names {
+			k.Nodes[name] = &node.Node{Name: name}
+		}
+	})
+}
 
+// updateKubeWithRetry applies delta to the current kube record and writes it
+// back with UpdateWithPrecondition, re-fetching and reapplying delta on a
+// resource-version conflict instead of giving up after the first race.
+func (h *Handler) updateKubeWithRetry(ctx context.Context, kname string, delta func(k *model.Kube)) error {
+	var lastErr error
+
+	for attempt := 0; attempt < updateKubeMaxRetries; attempt++ {
+		k, resourceVersion, err := h.svc.Get(ctx, kname)
 		if err != nil {
-			logrus.Errorf("update cluster %s caused %v", kname, err)
+			return errors.Wrap(err, "get kube")
 		}
-	}()
-	w.WriteHeader(http.StatusAccepted)
+
+		delta(k)
+
+		err = h.svc.UpdateWithPrecondition(ctx, k, resourceVersion)
+		if err == nil {
+			return nil
+		}
+
+		if !sgerrors.IsConflict(err) {
+			return errors.Wrap(err, "update kube")
+		}
+
+		lastErr = err
+		logrus.Warnf("conflict updating cluster %s, retrying (%d/%d)",
+			kname, attempt+1, updateKubeMaxRetries)
+
+		backoff := time.Duration(attempt+1) * updateKubeRetryJitter
+		backoff += time.Duration(rand.Intn(int(updateKubeRetryJitter)))
+		time.Sleep(backoff)
+	}
+
+	return errors.Wrap(lastErr, "update kube: exhausted retries on resource version conflict")
 }
 
 // TODO(stgleb): Create separte task service to manage task object lifecycle
@@ -0,0 +1,71 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/node"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+// fakeKubeService is a minimal Interface fake that lets tests control how
+// many times UpdateWithPrecondition reports a conflict before succeeding.
+type fakeKubeService struct {
+	Interface
+	kube            *model.Kube
+	resourceVersion int64
+	conflictsLeft   int
+	updateCalls     int
+}
+
+func (f *fakeKubeService) Get(ctx context.Context, kname string) (*model.Kube, int64, error) {
+	return f.kube, f.resourceVersion, nil
+}
+
+func (f *fakeKubeService) UpdateWithPrecondition(ctx context.Context, k *model.Kube, resourceVersion int64) error {
+	f.updateCalls++
+
+	if f.conflictsLeft > 0 {
+		f.conflictsLeft--
+		return sgerrors.ErrConflict
+	}
+
+	f.kube = k
+	f.resourceVersion++
+	return nil
+}
+
+func TestUpdateKubeWithRetry_RetriesOnConflict(t *testing.T) {
+	svc := &fakeKubeService{
+		kube:          &model.Kube{Name: "test", Nodes: map[string]*node.Node{"node-1": {Name: "node-1"}}},
+		conflictsLeft: 2,
+	}
+	h := &Handler{svc: svc}
+
+	err := h.removeNodeFromKube(context.Background(), "test", "node-1")
+	if err != nil {
+		t.Fatalf("expected retry to eventually succeed, got: %v", err)
+	}
+
+	if _, ok := svc.kube.Nodes["node-1"]; ok {
+		t.Fatalf("expected node-1 to be removed from the kube record")
+	}
+
+	if svc.updateCalls != 3 {
+		t.Fatalf("expected 2 conflicting attempts + 1 successful write, got %d calls", svc.updateCalls)
+	}
+}
+
+func TestUpdateKubeWithRetry_ExhaustsRetries(t *testing.T) {
+	svc := &fakeKubeService{
+		kube:          &model.Kube{Name: "test", Nodes: map[string]*node.Node{}},
+		conflictsLeft: updateKubeMaxRetries,
+	}
+	h := &Handler{svc: svc}
+
+	err := h.addNodesToKube(context.Background(), "test", []string{"node-2"})
+	if err == nil {
+		t.Fatal("expected error after exhausting retries on a permanent conflict")
+	}
+}
@@ -0,0 +1,72 @@
+package kube
+
+import (
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// restClientGetter adapts a stored kubeconfig blob into the
+// genericclioptions.RESTClientGetter Helm's action.Configuration needs in
+// order to talk to a provisioned cluster.
+type restClientGetter struct {
+	clientConfig clientcmd.ClientConfig
+}
+
+// newRESTClientGetter builds a restClientGetter from a raw kubeconfig,
+// scoped to namespace for the parts of Helm that honor it.
+func newRESTClientGetter(kubeconfig []byte, namespace string) (genericclioptions.RESTClientGetter, error) {
+	rawConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig := clientcmd.NewDefaultClientConfig(*rawConfig, &clientcmd.ConfigOverrides{
+		Context: clientcmdapi.Context{Namespace: namespace},
+	})
+
+	return &restClientGetter{clientConfig: clientConfig}, nil
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.clientConfig.ClientConfig()
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	cfg, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return memory.NewMemCacheClient(dc), nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return restmapper.NewDeferredDiscoveryRESTMapper(dc), nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return g.clientConfig
+}
+
+// logrusDebugf adapts logrus to the action.DebugLog signature Helm's
+// action.Configuration expects for its internal logging.
+func logrusDebugf(format string, v ...interface{}) {
+	logrus.Debugf(format, v...)
+}
@@ -0,0 +1,281 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+// releasePrefix is the storage.Interface prefix releases are persisted
+// under, mirroring workflows.Prefix for tasks.
+const releasePrefix = "releases"
+
+// Release is the persisted record of a Helm release installed onto a kube.
+type Release struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Chart     string    `json:"chart"`
+	Version   string    `json:"version"`
+	Revision  int       `json:"revision"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ReleaseService implements releaseManager on top of Helm's Go SDK,
+// persisting release metadata in the same storage.Interface the rest of
+// the kube package uses for tasks and kube records.
+type ReleaseService struct {
+	kubeService Interface
+	repo        storage.Interface
+}
+
+// NewReleaseService returns a ReleaseService backed by repo.
+func NewReleaseService(kubeService Interface, repo storage.Interface) *ReleaseService {
+	return &ReleaseService{
+		kubeService: kubeService,
+		repo:        repo,
+	}
+}
+
+func (s *ReleaseService) Install(ctx context.Context, k *model.Kube, req ReleaseInstallRequest) (*Release, error) {
+	cfg, err := s.actionConfig(ctx, k, req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(cfg)
+	install.ReleaseName = req.Name
+	install.Namespace = req.Namespace
+	install.Version = req.Version
+	install.ChartPathOptions.RepoURL = req.Repo
+
+	chrt, err := s.loadChart(install.ChartPathOptions, req.Chart)
+	if err != nil {
+		return nil, errors.Wrap(err, "load chart")
+	}
+
+	rel, err := install.RunWithContext(ctx, chrt, req.Values)
+	if err != nil {
+		return nil, errors.Wrap(err, "install release")
+	}
+
+	out := fromHelmRelease(rel)
+	if err := s.save(ctx, k.Name, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *ReleaseService) List(ctx context.Context, kname string) ([]*Release, error) {
+	data, err := s.repo.GetAll(ctx, fmt.Sprintf("%s/%s", releasePrefix, kname))
+	if err != nil {
+		return nil, errors.Wrap(err, "list releases")
+	}
+
+	releases := make([]*Release, 0, len(data))
+	for _, v := range data {
+		rel := &Release{}
+		if err := json.Unmarshal(v, rel); err != nil {
+			return nil, errors.Wrap(err, "unmarshal release")
+		}
+		releases = append(releases, rel)
+	}
+
+	return releases, nil
+}
+
+func (s *ReleaseService) Get(ctx context.Context, kname, rname string) (*Release, error) {
+	data, err := s.repo.Get(ctx, fmt.Sprintf("%s/%s", releasePrefix, kname), rname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			return nil, sgerrors.ErrNotFound
+		}
+		return nil, errors.Wrap(err, "get release")
+	}
+
+	rel := &Release{}
+	if err := json.Unmarshal(data, rel); err != nil {
+		return nil, errors.Wrap(err, "unmarshal release")
+	}
+
+	return rel, nil
+}
+
+func (s *ReleaseService) Upgrade(ctx context.Context, k *model.Kube, rname string, req ReleaseUpgradeRequest) (*Release, error) {
+	cfg, err := s.actionConfigForRelease(ctx, k, rname)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Version = req.Version
+	upgrade.ChartPathOptions.RepoURL = req.Repo
+
+	chrt, err := s.loadChart(upgrade.ChartPathOptions, req.Chart)
+	if err != nil {
+		return nil, errors.Wrap(err, "load chart")
+	}
+
+	rel, err := upgrade.RunWithContext(ctx, rname, chrt, req.Values)
+	if err != nil {
+		return nil, errors.Wrap(err, "upgrade release")
+	}
+
+	out := fromHelmRelease(rel)
+	if err := s.save(ctx, k.Name, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func (s *ReleaseService) Uninstall(ctx context.Context, k *model.Kube, rname string, keepHistory bool) error {
+	cfg, err := s.actionConfigForRelease(ctx, k, rname)
+	if err != nil {
+		return err
+	}
+
+	uninstall := action.NewUninstall(cfg)
+	uninstall.KeepHistory = keepHistory
+
+	if _, err := uninstall.Run(rname); err != nil {
+		return errors.Wrap(err, "uninstall release")
+	}
+
+	if !keepHistory {
+		if err := s.repo.Delete(ctx, fmt.Sprintf("%s/%s", releasePrefix, k.Name), rname); err != nil {
+			return errors.Wrap(err, "delete release record")
+		}
+	}
+
+	return nil
+}
+
+func (s *ReleaseService) Rollback(ctx context.Context, k *model.Kube, rname string, toRevision int) (*Release, error) {
+	cfg, err := s.actionConfigForRelease(ctx, k, rname)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback := action.NewRollback(cfg)
+	rollback.Version = toRevision
+
+	if err := rollback.Run(rname); err != nil {
+		return nil, errors.Wrap(err, "rollback release")
+	}
+
+	rel, err := action.NewGet(cfg).Run(rname)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release after rollback")
+	}
+
+	out := fromHelmRelease(rel)
+	if err := s.save(ctx, k.Name, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// actionConfigForRelease builds a Helm action.Configuration scoped to rname's
+// own persisted namespace, rather than the default namespace an empty string
+// would resolve to - an Upgrade/Uninstall/Rollback of a release installed
+// outside the default namespace would otherwise silently target the wrong
+// one.
+func (s *ReleaseService) actionConfigForRelease(ctx context.Context, k *model.Kube, rname string) (*action.Configuration, error) {
+	rel, err := s.Get(ctx, k.Name, rname)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release")
+	}
+
+	return s.actionConfig(ctx, k, rel.Namespace)
+}
+
+// actionConfig builds a Helm action.Configuration authenticated against k's
+// cluster using the certs/kubeconfig already stored for it.
+func (s *ReleaseService) actionConfig(ctx context.Context, k *model.Kube, namespace string) (*action.Configuration, error) {
+	certs, err := s.kubeService.GetCerts(ctx, k.Name, "admin")
+	if err != nil {
+		return nil, errors.Wrap(err, "get cluster certs")
+	}
+
+	getter, err := newRESTClientGetter(certs, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "build kube client")
+	}
+
+	cfg := &action.Configuration{}
+	if err := cfg.Init(getter, namespace, "secrets", logrusDebugf); err != nil {
+		return nil, errors.Wrap(err, "init helm action config")
+	}
+
+	return cfg, nil
+}
+
+func (s *ReleaseService) loadChart(opts action.ChartPathOptions, name string) (*chart.Chart, error) {
+	settings := cli.New()
+
+	path, err := opts.LocateChart(name, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	chrt, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if req := chrt.Metadata.Dependencies; req != nil {
+		if err := action.CheckDependencies(chrt, req); err != nil {
+			return nil, errors.Wrap(err, "missing chart dependencies")
+		}
+	}
+
+	return chrt, nil
+}
+
+func (s *ReleaseService) save(ctx context.Context, kname string, rel *Release) error {
+	data, err := json.Marshal(rel)
+	if err != nil {
+		return errors.Wrap(err, "marshal release")
+	}
+
+	if err := s.repo.Put(ctx, fmt.Sprintf("%s/%s", releasePrefix, kname), rel.Name, data); err != nil {
+		return errors.Wrap(err, "save release")
+	}
+
+	return nil
+}
+
+func fromHelmRelease(rel *release.Release) *Release {
+	out := &Release{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		UpdatedAt: time.Now(),
+	}
+
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		out.Chart = rel.Chart.Metadata.Name
+		out.Version = rel.Chart.Metadata.Version
+	}
+
+	if rel.Info != nil {
+		out.Status = rel.Info.Status.String()
+	}
+
+	return out
+}
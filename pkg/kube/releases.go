@@ -0,0 +1,262 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/message"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+const releaseNameSuffixChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// generateReleaseName mimics Helm 3's own --generate-name: a chart's base
+// name plus a short random suffix, used when a ReleaseInstallRequest omits
+// Name.
+func generateReleaseName(chart string) string {
+	base := path.Base(strings.TrimRight(chart, "/"))
+	if base == "" || base == "." {
+		base = "release"
+	}
+
+	suffix := make([]byte, 5)
+	for i := range suffix {
+		suffix[i] = releaseNameSuffixChars[rand.Intn(len(releaseNameSuffixChars))]
+	}
+
+	return fmt.Sprintf("%s-%s", base, suffix)
+}
+
+// releaseEvent is a single line appended to a release's task stream so the
+// UI can show install/upgrade/rollback progress alongside provisioning
+// tasks, the same way workflow steps do.
+type releaseEvent struct {
+	Release string `json:"release"`
+	Status  string `json:"status"`
+}
+
+// emitReleaseEvent appends a progress line to the release's task stream.
+// Failure to do so is logged, not fatal - it must never fail the request.
+func (h *Handler) emitReleaseEvent(kname, rname, status string) {
+	writer, err := h.getWriter(fmt.Sprintf("release-%s-%s", kname, rname))
+	if err != nil {
+		logrus.Warnf("open release event stream for %s/%s: %v", kname, rname, err)
+		return
+	}
+	defer writer.Close()
+
+	if err := json.NewEncoder(writer).Encode(releaseEvent{Release: rname, Status: status}); err != nil {
+		logrus.Warnf("write release event for %s/%s: %v", kname, rname, err)
+	}
+}
+
+// ReleaseInstallRequest is the body of POST /kubes/{kname}/releases. Name is
+// optional - if omitted, one is generated from Chart the same way Helm's
+// own --generate-name does.
+type ReleaseInstallRequest struct {
+	Name      string                 `json:"name"`
+	Chart     string                 `json:"chart"`
+	Repo      string                 `json:"repo"`
+	Version   string                 `json:"version"`
+	Namespace string                 `json:"namespace"`
+	Values    map[string]interface{} `json:"values"`
+}
+
+// ReleaseUpgradeRequest is the body of PUT /kubes/{kname}/releases/{rname}.
+type ReleaseUpgradeRequest struct {
+	Chart   string                 `json:"chart"`
+	Repo    string                 `json:"repo"`
+	Version string                 `json:"version"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+func (h *Handler) installRelease(w http.ResponseWriter, r *http.Request) {
+	kname := mux.Vars(r)["kname"]
+
+	k, _, err := h.svc.Get(r.Context(), kname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, kname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	req := ReleaseInstallRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		message.SendInvalidJSON(w, err)
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = generateReleaseName(req.Chart)
+	}
+
+	h.emitReleaseEvent(kname, req.Name, "installing")
+	rel, err := h.releaseService.Install(r.Context(), k, req)
+	if err != nil {
+		h.emitReleaseEvent(kname, req.Name, "failed")
+		message.SendUnknownError(w, err)
+		return
+	}
+	h.emitReleaseEvent(kname, req.Name, "deployed")
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(rel); err != nil {
+		message.SendUnknownError(w, err)
+	}
+}
+
+func (h *Handler) listReleases(w http.ResponseWriter, r *http.Request) {
+	kname := mux.Vars(r)["kname"]
+
+	releases, err := h.releaseService.List(r.Context(), kname)
+	if err != nil {
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(releases); err != nil {
+		message.SendUnknownError(w, err)
+	}
+}
+
+func (h *Handler) getRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kname, rname := vars["kname"], vars["rname"]
+
+	rel, err := h.releaseService.Get(r.Context(), kname, rname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, rname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(rel); err != nil {
+		message.SendUnknownError(w, err)
+	}
+}
+
+func (h *Handler) upgradeRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kname, rname := vars["kname"], vars["rname"]
+
+	k, _, err := h.svc.Get(r.Context(), kname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, kname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	req := ReleaseUpgradeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		message.SendInvalidJSON(w, err)
+		return
+	}
+
+	h.emitReleaseEvent(kname, rname, "upgrading")
+	rel, err := h.releaseService.Upgrade(r.Context(), k, rname, req)
+	if err != nil {
+		h.emitReleaseEvent(kname, rname, "failed")
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, rname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+	h.emitReleaseEvent(kname, rname, "deployed")
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(rel); err != nil {
+		message.SendUnknownError(w, err)
+	}
+}
+
+func (h *Handler) uninstallRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kname, rname := vars["kname"], vars["rname"]
+	keepHistory := r.URL.Query().Get("keep-history") == "true"
+
+	k, _, err := h.svc.Get(r.Context(), kname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, kname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	h.emitReleaseEvent(kname, rname, "uninstalling")
+	if err := h.releaseService.Uninstall(r.Context(), k, rname, keepHistory); err != nil {
+		h.emitReleaseEvent(kname, rname, "failed")
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, rname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+	h.emitReleaseEvent(kname, rname, "uninstalled")
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) rollbackRelease(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kname, rname := vars["kname"], vars["rname"]
+
+	k, _, err := h.svc.Get(r.Context(), kname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, kname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	toRevision := 0
+	if raw := r.URL.Query().Get("revision"); raw != "" {
+		rev, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "revision must be an integer", http.StatusBadRequest)
+			return
+		}
+		toRevision = rev
+	}
+
+	h.emitReleaseEvent(kname, rname, "rolling back")
+	rel, err := h.releaseService.Rollback(r.Context(), k, rname, toRevision)
+	if err != nil {
+		h.emitReleaseEvent(kname, rname, "failed")
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, rname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+	h.emitReleaseEvent(kname, rname, "rolled back")
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(rel); err != nil {
+		message.SendUnknownError(w, err)
+	}
+}
@@ -0,0 +1,22 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateReleaseName(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                "nginx",
+		"stable/nginx-ingress": "nginx-ingress",
+		"./charts/mychart":     "mychart",
+		"":                     "release",
+	}
+
+	for chart, wantPrefix := range cases {
+		name := generateReleaseName(chart)
+		if !strings.HasPrefix(name, wantPrefix+"-") {
+			t.Errorf("generateReleaseName(%q) = %q, want prefix %q-", chart, name, wantPrefix)
+		}
+	}
+}
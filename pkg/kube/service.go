@@ -0,0 +1,171 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/storage"
+)
+
+// kubePrefix is the storage.Interface prefix kube records are persisted
+// under. certPrefix holds the raw kubeconfig blobs provisioning writes for
+// each cluster, keyed by "{kname}/{cname}".
+const (
+	kubePrefix = "kubes"
+	certPrefix = "certs"
+)
+
+// Interface is the persistence contract Handler drives kube records
+// through. Get's second return value is the record's storage resource
+// version (an etcd mod-revision); round-tripping it into
+// UpdateWithPrecondition lets the caller detect - instead of silently
+// clobbering - a write that happened in between.
+type Interface interface {
+	Create(ctx context.Context, k *model.Kube) error
+	Get(ctx context.Context, kname string) (*model.Kube, int64, error)
+	ListAll(ctx context.Context) ([]*model.Kube, error)
+	Delete(ctx context.Context, kname string) error
+	ListKubeResources(ctx context.Context, kname string) ([]byte, error)
+	GetKubeResources(ctx context.Context, kname, resource, namespace, name string) ([]byte, error)
+	GetCerts(ctx context.Context, kname, cname string) ([]byte, error)
+
+	// UpdateWithPrecondition writes k back only if its storage resource
+	// version still matches resourceVersion, returning sgerrors.ErrConflict
+	// otherwise so the caller can re-fetch, reapply its delta, and retry.
+	UpdateWithPrecondition(ctx context.Context, k *model.Kube, resourceVersion int64) error
+}
+
+// Service is the etcd-backed Interface implementation.
+type Service struct {
+	repo storage.Interface
+}
+
+// NewService returns a Service backed by repo.
+func NewService(repo storage.Interface) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) Create(ctx context.Context, k *model.Kube) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return errors.Wrap(err, "marshal kube")
+	}
+
+	return s.repo.Put(ctx, kubePrefix, k.Name, data)
+}
+
+func (s *Service) Get(ctx context.Context, kname string) (*model.Kube, int64, error) {
+	data, resourceVersion, err := s.repo.GetWithRevision(ctx, kubePrefix, kname)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "get kube")
+	}
+
+	k := &model.Kube{}
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, 0, errors.Wrap(err, "unmarshal kube")
+	}
+
+	return k, resourceVersion, nil
+}
+
+func (s *Service) UpdateWithPrecondition(ctx context.Context, k *model.Kube, resourceVersion int64) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return errors.Wrap(err, "marshal kube")
+	}
+
+	return s.repo.CompareAndSwap(ctx, kubePrefix, k.Name, data, resourceVersion)
+}
+
+func (s *Service) ListAll(ctx context.Context) ([]*model.Kube, error) {
+	data, err := s.repo.GetAll(ctx, kubePrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "list kubes")
+	}
+
+	kubes := make([]*model.Kube, 0, len(data))
+	for _, v := range data {
+		k := &model.Kube{}
+		if err := json.Unmarshal(v, k); err != nil {
+			return nil, errors.Wrap(err, "unmarshal kube")
+		}
+		kubes = append(kubes, k)
+	}
+
+	return kubes, nil
+}
+
+func (s *Service) Delete(ctx context.Context, kname string) error {
+	return s.repo.Delete(ctx, kubePrefix, kname)
+}
+
+func (s *Service) GetCerts(ctx context.Context, kname, cname string) ([]byte, error) {
+	data, err := s.repo.Get(ctx, certPrefix, fmt.Sprintf("%s/%s", kname, cname))
+	if err != nil {
+		return nil, errors.Wrap(err, "get cluster certs")
+	}
+
+	return data, nil
+}
+
+func (s *Service) ListKubeResources(ctx context.Context, kname string) ([]byte, error) {
+	return s.GetKubeResources(ctx, kname, "pods", "", "")
+}
+
+// GetKubeResources queries the cluster's own API, rather than anything in
+// etcd, so it needs a live dynamic client built from the stored admin
+// kubeconfig the same way ReleaseService builds one for Helm.
+func (s *Service) GetKubeResources(ctx context.Context, kname, resource, namespace, name string) ([]byte, error) {
+	kubeconfig, err := s.GetCerts(ctx, kname, "admin")
+	if err != nil {
+		return nil, err
+	}
+
+	getter, err := newRESTClientGetter(kubeconfig, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "build kube client")
+	}
+
+	restConfig, err := getter.ToRESTConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "build rest config")
+	}
+
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "build dynamic client")
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: resource}
+	var ns dynamic.ResourceInterface = client.Resource(gvr)
+	if namespace != "" {
+		ns = client.Resource(gvr).Namespace(namespace)
+	}
+
+	var (
+		obj interface{}
+	)
+	if name != "" {
+		obj, err = ns.Get(ctx, name, metav1.GetOptions{})
+	} else {
+		obj, err = ns.List(ctx, metav1.ListOptions{})
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "get kube resources")
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return json.Marshal(u)
+	}
+
+	return json.Marshal(obj)
+}
@@ -0,0 +1,153 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/supergiant/supergiant/pkg/message"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+// streamTasks upgrades the connection to text/event-stream and streams
+// kname's task progress, replacing the need to poll getTasks. On connect it
+// replays the step statuses persisted in repo as `event: step`; after that
+// it forwards what workflows.Bus publishes for kname, as `event: step` for a
+// step status and `event: task-complete` once a task finishes. Runner
+// publishes only the task-complete marker, so step transitions made while
+// the stream is open are not pushed live; they show up in the replay of the
+// next connection. A 15s heartbeat comment keeps intermediaries from
+// closing an idle connection.
+// Clients that reconnect with Last-Event-ID only receive step statuses
+// recorded after that event.
+func (h *Handler) streamTasks(w http.ResponseWriter, r *http.Request) {
+	kname := mux.Vars(r)["kname"]
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before reading the snapshot, not after: anything published
+	// in between is then guaranteed to land in sub even if it also makes it
+	// into the snapshot, closing the window where an update published
+	// between the two could be dropped entirely. Overlap is deduped below.
+	sub, unsubscribe := workflows.Bus.Subscribe(kname)
+	defer unsubscribe()
+
+	tasks, err := h.getKubeTasks(r.Context(), kname)
+	if err != nil {
+		if sgerrors.IsNotFound(err) {
+			message.SendNotFound(w, kname, err)
+			return
+		}
+		message.SendUnknownError(w, err)
+		return
+	}
+
+	sortTasksByID(tasks)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	lastEventID := 0
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		if id, err := strconv.Atoi(raw); err == nil {
+			lastEventID = id
+		}
+	}
+
+	eventID := 0
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		for _, status := range task.StepStatuses {
+			eventID++
+			seen[encodeOrEmpty(status)] = true
+			if eventID <= lastEventID {
+				continue
+			}
+			writeStepEvent(w, eventID, status)
+		}
+	}
+	flusher.Flush()
+
+	// Drain anything sub already buffered during the snapshot read above,
+	// skipping updates whose content was already part of the replay.
+	for drained := false; !drained; {
+		select {
+		case update, ok := <-sub:
+			if !ok {
+				return
+			}
+			if seen[encodeOrEmpty(update.Status)] {
+				continue
+			}
+			eventID++
+			writeUpdateEvent(w, eventID, update)
+			flusher.Flush()
+		default:
+			drained = true
+		}
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case update, ok := <-sub:
+			if !ok {
+				return
+			}
+			eventID++
+			writeUpdateEvent(w, eventID, update)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeUpdateEvent(w http.ResponseWriter, id int, update workflows.TaskUpdate) {
+	if update.TaskComplete {
+		fmt.Fprintf(w, "id: %d\nevent: task-complete\ndata: %s\n\n", id, encodeOrEmpty(update.Status))
+		return
+	}
+	writeStepEvent(w, id, update.Status)
+}
+
+// sortTasksByID gives tasks a stable order. getKubeTasks builds tasks from
+// repo.GetAll, which ranges over a map - order isn't stable across calls -
+// so without this the same step status gets a different eventID on every
+// reconnect and Last-Event-ID resume silently skips or re-delivers events.
+func sortTasksByID(tasks []*workflows.Task) {
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+}
+
+func writeStepEvent(w http.ResponseWriter, id int, status workflows.StepStatus) {
+	fmt.Fprintf(w, "id: %d\nevent: step\ndata: %s\n\n", id, encodeOrEmpty(status))
+}
+
+func encodeOrEmpty(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logrus.Warnf("marshal sse event: %v", err)
+		return "{}"
+	}
+	return string(data)
+}
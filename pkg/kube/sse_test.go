@@ -0,0 +1,28 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+func TestSortTasksByID_StableAcrossCalls(t *testing.T) {
+	a := &workflows.Task{ID: "a"}
+	b := &workflows.Task{ID: "b"}
+	c := &workflows.Task{ID: "c"}
+
+	inputs := [][]*workflows.Task{
+		{c, a, b},
+		{b, c, a},
+		{a, b, c},
+	}
+
+	for _, tasks := range inputs {
+		sortTasksByID(tasks)
+
+		if tasks[0].ID != "a" || tasks[1].ID != "b" || tasks[2].ID != "c" {
+			t.Fatalf("expected a stable a,b,c order regardless of input order, got %s,%s,%s",
+				tasks[0].ID, tasks[1].ID, tasks[2].ID)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package digitalocean wires DigitalOcean into workflows.DefaultRegistry so
+// it's a first-class provider without workflows or kube needing to know
+// about it by name.
+package digitalocean
+
+import (
+	"github.com/supergiant/supergiant/pkg/clouds"
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/profile"
+	"github.com/supergiant/supergiant/pkg/workflows"
+)
+
+func init() {
+	workflows.Register(clouds.DigitalOcean, workflows.WorkflowSet{
+		DeleteCluster:    workflows.DigitalOceanDeleteCluster,
+		DeleteNode:       workflows.DigitalOceanDeleteNode,
+		ProvisionCluster: workflows.DigitalOceanProvisionCluster,
+		ProvisionNode:    workflows.DigitalOceanProvisionNode,
+		UpgradeK8s:       workflows.DigitalOceanUpgradeK8s,
+		NodeProfile:      nodeProfile,
+	})
+}
+
+// nodeProfile builds the profile a new node provisions with, mirroring the
+// cluster's own settings the same way the initial master/node set was
+// built at createKube time.
+func nodeProfile(k *model.Kube) profile.Profile {
+	return profile.Profile{
+		Provider:        clouds.DigitalOcean,
+		Region:          k.Region,
+		Arch:            k.Arch,
+		OperatingSystem: k.OperatingSystem,
+		UbuntuVersion:   k.OperatingSystemVersion,
+		DockerVersion:   k.DockerVersion,
+		K8SVersion:      k.K8SVersion,
+		HelmVersion:     k.HelmVersion,
+
+		NetworkType:    k.Networking.Type,
+		CIDR:           k.Networking.CIDR,
+		FlannelVersion: k.Networking.Version,
+
+		NodesProfiles: []profile.NodeProfile{
+			{},
+		},
+
+		RBACEnabled: k.RBACEnabled,
+	}
+}
@@ -0,0 +1,33 @@
+// Package sgerrors holds the sentinel errors shared across Supergiant's
+// service layer so callers can branch on error class (not found, conflict,
+// ...) without depending on a particular storage backend's error types.
+package sgerrors
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrNotFound is returned when a requested entity doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write loses an optimistic-concurrency
+	// check, e.g. a storage.Interface CompareAndSwap against a stale
+	// resource version.
+	ErrConflict = errors.New("conflict")
+	// ErrUnsupportedProvider is returned when no workflows.WorkflowSet is
+	// registered for a cloud provider.
+	ErrUnsupportedProvider = errors.New("unsupported provider")
+)
+
+// IsNotFound reports whether err is or wraps ErrNotFound.
+func IsNotFound(err error) bool {
+	return errors.Cause(err) == ErrNotFound
+}
+
+// IsConflict reports whether err is or wraps ErrConflict.
+func IsConflict(err error) bool {
+	return errors.Cause(err) == ErrConflict
+}
+
+// IsUnsupportedProvider reports whether err is or wraps ErrUnsupportedProvider.
+func IsUnsupportedProvider(err error) bool {
+	return errors.Cause(err) == ErrUnsupportedProvider
+}
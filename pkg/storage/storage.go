@@ -0,0 +1,113 @@
+// Package storage is the key/value layer the rest of Supergiant persists
+// its state through. It's a thin wrapper over etcd, scoping every key under
+// a prefix (e.g. "kubes", "tasks/...") the same way the rest of the code
+// already namespaces its records.
+package storage
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+// Interface is the storage contract every package in Supergiant that
+// persists state (kube records, tasks, releases, ...) is built on.
+type Interface interface {
+	Get(ctx context.Context, prefix, key string) ([]byte, error)
+	GetAll(ctx context.Context, prefix string) (map[string][]byte, error)
+	Put(ctx context.Context, prefix, key string, value []byte) error
+	Delete(ctx context.Context, prefix, key string) error
+
+	// GetWithRevision is Get, but also returns the key's etcd mod-revision
+	// so the caller can round-trip it into a later CompareAndSwap instead
+	// of blindly overwriting a concurrent write.
+	GetWithRevision(ctx context.Context, prefix, key string) ([]byte, int64, error)
+
+	// CompareAndSwap writes value under prefix/key only if the key's
+	// mod-revision still matches resourceVersion, returning
+	// sgerrors.ErrConflict if something else wrote to it in the meantime.
+	CompareAndSwap(ctx context.Context, prefix, key string, value []byte, resourceVersion int64) error
+}
+
+// ETCDStorage is the etcd-backed Interface implementation.
+type ETCDStorage struct {
+	client *clientv3.Client
+}
+
+// NewETCD wraps an existing etcd client as an Interface.
+func NewETCD(client *clientv3.Client) *ETCDStorage {
+	return &ETCDStorage{client: client}
+}
+
+func fullKey(prefix, key string) string {
+	return path.Join(prefix, key)
+}
+
+func (s *ETCDStorage) Get(ctx context.Context, prefix, key string) ([]byte, error) {
+	data, _, err := s.GetWithRevision(ctx, prefix, key)
+	return data, err
+}
+
+func (s *ETCDStorage) GetWithRevision(ctx context.Context, prefix, key string) ([]byte, int64, error) {
+	resp, err := s.client.Get(ctx, fullKey(prefix, key))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "etcd get")
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, 0, sgerrors.ErrNotFound
+	}
+
+	return resp.Kvs[0].Value, resp.Kvs[0].ModRevision, nil
+}
+
+func (s *ETCDStorage) GetAll(ctx context.Context, prefix string) (map[string][]byte, error) {
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "etcd get all")
+	}
+
+	out := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		out[string(kv.Key)] = kv.Value
+	}
+
+	return out, nil
+}
+
+func (s *ETCDStorage) Put(ctx context.Context, prefix, key string, value []byte) error {
+	_, err := s.client.Put(ctx, fullKey(prefix, key), string(value))
+	return errors.Wrap(err, "etcd put")
+}
+
+func (s *ETCDStorage) Delete(ctx context.Context, prefix, key string) error {
+	_, err := s.client.Delete(ctx, fullKey(prefix, key))
+	return errors.Wrap(err, "etcd delete")
+}
+
+// CompareAndSwap implements the optimistic-concurrency write: it only
+// commits if nothing has touched the key since resourceVersion was read,
+// using the same clientv3.Txn/Compare(ModRevision) primitive etcd's own
+// STM is built on.
+func (s *ETCDStorage) CompareAndSwap(ctx context.Context, prefix, key string, value []byte, resourceVersion int64) error {
+	full := fullKey(prefix, key)
+
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(full), "=", resourceVersion)).
+		Then(clientv3.OpPut(full, string(value))).
+		Commit()
+
+	if err != nil {
+		return errors.Wrap(err, "etcd txn")
+	}
+
+	if !resp.Succeeded {
+		return sgerrors.ErrConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package workflows
+
+import "sync"
+
+// TaskUpdate is a single event published to Bus - either a step's
+// StepStatus, or a terminal marker once the task finishes. Runner publishes
+// only the terminal marker; step transitions are not published.
+type TaskUpdate struct {
+	TaskComplete bool
+	Status       StepStatus
+}
+
+// Bus fans out TaskUpdates per kube name, so kube.Handler's SSE endpoint can
+// push live events to a connected client instead of only ever serving
+// getKubeTasks snapshots.
+var Bus = newBus()
+
+type taskBus struct {
+	mu   sync.Mutex
+	subs map[string][]chan TaskUpdate
+}
+
+func newBus() *taskBus {
+	return &taskBus{subs: make(map[string][]chan TaskUpdate)}
+}
+
+// Subscribe returns a channel of TaskUpdates published for kname, and an
+// unsubscribe func that releases it. The channel is buffered so a slow
+// subscriber falling behind can't block Publish.
+func (b *taskBus) Subscribe(kname string) (<-chan TaskUpdate, func()) {
+	ch := make(chan TaskUpdate, 16)
+
+	b.mu.Lock()
+	b.subs[kname] = append(b.subs[kname], ch)
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subs := b.subs[kname]
+		for i, s := range subs {
+			if s == ch {
+				b.subs[kname] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish fans update out to every current subscriber of kname. A
+// subscriber whose buffer is full drops the update rather than blocking the
+// publisher; a dropped update is not redelivered on that subscription.
+func (b *taskBus) Publish(kname string, update TaskUpdate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subs[kname] {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package workflows
+
+import (
+	"sync"
+
+	"github.com/supergiant/supergiant/pkg/clouds"
+	"github.com/supergiant/supergiant/pkg/model"
+	"github.com/supergiant/supergiant/pkg/profile"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+// WorkflowSet names the workflows a provider implements, plus the hooks the
+// kube package needs to provision a node without building a profile ad hoc
+// itself.
+type WorkflowSet struct {
+	DeleteCluster    string
+	DeleteNode       string
+	ProvisionCluster string
+	ProvisionNode    string
+	UpgradeK8s       string
+
+	// NodeProfile builds the profile.Profile a new node provisions with,
+	// given the cluster it's joining. Every provider supplies its own -
+	// kube.Handler must not construct one itself.
+	NodeProfile func(k *model.Kube) profile.Profile
+}
+
+// Registry looks up a provider's WorkflowSet, populated by that provider's
+// own init() via Register. This replaces a single hardcoded map entry in
+// kube.NewHandler, so adding a provider no longer means editing that
+// package.
+type Registry struct {
+	mu  sync.RWMutex
+	set map[clouds.Name]WorkflowSet
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{set: make(map[clouds.Name]WorkflowSet)}
+}
+
+// DefaultRegistry is the registry every provider subpackage registers
+// itself into from its own init().
+var DefaultRegistry = NewRegistry()
+
+// Register adds (or replaces) name's WorkflowSet in DefaultRegistry.
+func Register(name clouds.Name, ws WorkflowSet) {
+	DefaultRegistry.Register(name, ws)
+}
+
+// Register adds (or replaces) name's WorkflowSet.
+func (r *Registry) Register(name clouds.Name, ws WorkflowSet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set[name] = ws
+}
+
+// Lookup returns name's WorkflowSet, or sgerrors.ErrUnsupportedProvider if
+// nothing has registered one.
+func (r *Registry) Lookup(name clouds.Name) (WorkflowSet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ws, ok := r.set[name]
+	if !ok {
+		return WorkflowSet{}, sgerrors.ErrUnsupportedProvider
+	}
+
+	return ws, nil
+}
@@ -0,0 +1,31 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/clouds"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+)
+
+func TestRegistry_LookupUnregistered(t *testing.T) {
+	r := NewRegistry()
+
+	if _, err := r.Lookup(clouds.AWS); !sgerrors.IsUnsupportedProvider(err) {
+		t.Fatalf("expected ErrUnsupportedProvider for an unregistered provider, got %v", err)
+	}
+}
+
+func TestRegistry_RegisterThenLookup(t *testing.T) {
+	r := NewRegistry()
+	want := WorkflowSet{DeleteCluster: "aws-delete-cluster"}
+
+	r.Register(clouds.AWS, want)
+
+	got, err := r.Lookup(clouds.AWS)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got.DeleteCluster != want.DeleteCluster {
+		t.Fatalf("Lookup returned %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,356 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/concurrency"
+	"golang.org/x/time/rate"
+
+	"github.com/supergiant/supergiant/pkg/clouds"
+	"github.com/supergiant/supergiant/pkg/sgerrors"
+	"github.com/supergiant/supergiant/pkg/storage"
+	"github.com/supergiant/supergiant/pkg/util"
+	"github.com/supergiant/supergiant/pkg/workflows/steps"
+)
+
+// leaderElectionKey is the etcd key Runner replicas campaign on so exactly
+// one of them drains the queue at a time - otherwise two replicas could
+// race to run the same task after an etcd restart.
+const leaderElectionKey = "workflows/leader"
+
+const (
+	queueStatusPending = "pending"
+	queueStatusRunning = "running"
+	queueStatusDone    = "done"
+	queueStatusFailed  = "failed"
+)
+
+// rateLimits is the default per-provider token-bucket budget. It's
+// conservative on purpose - comfortably under each cloud's own API
+// throttle - since a burst of deletions is exactly the case this queue
+// exists to smooth out.
+var rateLimits = map[clouds.Name]rate.Limit{
+	clouds.DigitalOcean: 5, // calls/sec, burst 10 (see defaultBurst below)
+}
+
+const defaultBurst = 10
+
+// queuedTask is the etcd-persisted record behind a pending Enqueue call -
+// enough to reconstruct and dispatch the task after a restart, without
+// depending on the replica that enqueued it still being alive. Status is
+// the single source of truth callers poll via AwaitCompletion, since only
+// the elected leader actually dispatches the task and that may not be the
+// replica that enqueued it.
+type queuedTask struct {
+	Task     *Task        `json:"task"`
+	Provider clouds.Name  `json:"provider"`
+	Config   steps.Config `json:"config"`
+	Status   string       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// Runner enqueues tasks into etcd instead of running them inline. Only the
+// elected leader among Supergiant's HA replicas drains the queue, and a
+// worker pool dispatches through a per-provider rate limiter to stay under
+// cloud API throttles. Completion is observed by polling queuedTask.Status
+// rather than an in-process callback, so AwaitCompletion works the same
+// whether or not this replica is the one that ran the task.
+type Runner struct {
+	client    *clientv3.Client
+	repo      storage.Interface
+	getWriter func(string) (io.WriteCloser, error)
+
+	mu       sync.Mutex
+	limiters map[clouds.Name]*rate.Limiter
+	inFlight map[clouds.Name]int
+}
+
+// DefaultRunner is the Runner kube.Handler enqueues deletions onto. It's
+// inert until Init is called from startup, once an etcd client exists -
+// the same lazy-wiring pattern DefaultRegistry uses for provider init()s.
+var DefaultRunner = &Runner{}
+
+// Init wires client/repo into r and starts its leader-election campaign and
+// worker pool. Safe to call once at startup.
+func (r *Runner) Init(client *clientv3.Client, repo storage.Interface) {
+	r.client = client
+	r.repo = repo
+	r.getWriter = util.GetWriter
+	r.limiters = make(map[clouds.Name]*rate.Limiter, len(rateLimits))
+	r.inFlight = make(map[clouds.Name]int)
+
+	for name, limit := range rateLimits {
+		r.limiters[name] = rate.NewLimiter(limit, defaultBurst)
+	}
+
+	go r.campaignAndDrain(context.Background())
+}
+
+// Enqueue persists t as a pending task rather than running it inline, so
+// the leader's worker pool picks it up under provider's rate limiter.
+// Callers that need to act once t finishes must poll AwaitCompletion rather
+// than register a callback here, since the replica that calls Enqueue isn't
+// necessarily the one whose worker pool ends up dispatching it.
+func (r *Runner) Enqueue(ctx context.Context, t *Task, provider clouds.Name, config steps.Config) error {
+	data, err := json.Marshal(queuedTask{Task: t, Provider: provider, Config: config, Status: queueStatusPending})
+	if err != nil {
+		return errors.Wrap(err, "marshal queued task")
+	}
+
+	if err := r.repo.Put(ctx, Prefix, t.ID, data); err != nil {
+		return errors.Wrap(err, "enqueue task")
+	}
+
+	return nil
+}
+
+// AwaitCompletion polls taskID's persisted status until it reaches a
+// terminal state, returning the task's error if it failed. Every replica
+// can call this for a task it enqueued, regardless of which replica's
+// worker pool actually ran it - the queue status in etcd is shared state,
+// not a replica-local callback.
+func (r *Runner) AwaitCompletion(ctx context.Context, taskID string) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		raw, err := r.repo.Get(ctx, Prefix, taskID)
+		if err != nil {
+			if sgerrors.IsNotFound(err) {
+				return nil
+			}
+			continue
+		}
+
+		qt := &queuedTask{}
+		if err := json.Unmarshal(raw, qt); err != nil {
+			continue
+		}
+
+		switch qt.Status {
+		case queueStatusDone:
+			return nil
+		case queueStatusFailed:
+			return errors.New(qt.Error)
+		}
+	}
+}
+
+// campaignAndDrain blocks campaigning for leadership, and once elected,
+// polls the queue for pending tasks and dispatches them through the rate
+// limiter until ctx is cancelled or leadership is lost.
+func (r *Runner) campaignAndDrain(ctx context.Context) {
+	for {
+		session, err := concurrency.NewSession(r.client)
+		if err != nil {
+			logrus.Errorf("workflows: new etcd session: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		election := concurrency.NewElection(session, leaderElectionKey)
+		if err := election.Campaign(ctx, "runner"); err != nil {
+			logrus.Errorf("workflows: campaign for leadership: %v", err)
+			session.Close()
+			time.Sleep(time.Second)
+			continue
+		}
+
+		logrus.Info("workflows: elected leader, draining task queue")
+		r.drainUntilDone(ctx, session)
+		session.Close()
+	}
+}
+
+// drainUntilDone runs while session (and thus leadership) is valid,
+// repeatedly scanning etcd for pending tasks and dispatching each once its
+// provider's rate limiter allows it.
+func (r *Runner) drainUntilDone(ctx context.Context, session *concurrency.Session) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-session.Done():
+			return
+		case <-ticker.C:
+			r.dispatchPending(ctx)
+		}
+	}
+}
+
+func (r *Runner) dispatchPending(ctx context.Context) {
+	all, err := r.repo.GetAll(ctx, Prefix)
+	if err != nil {
+		logrus.Errorf("workflows: list queue: %v", err)
+		return
+	}
+
+	for id, raw := range all {
+		qt := &queuedTask{}
+		if err := json.Unmarshal(raw, qt); err != nil {
+			continue
+		}
+		if qt.Status != queueStatusPending {
+			continue
+		}
+
+		limiter := r.limiterFor(qt.Provider)
+		if !limiter.Allow() {
+			continue
+		}
+
+		qt.Status = queueStatusRunning
+		data, err := json.Marshal(qt)
+		if err != nil {
+			continue
+		}
+		if err := r.repo.Put(ctx, Prefix, id, data); err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		r.inFlight[qt.Provider]++
+		r.mu.Unlock()
+
+		go r.run(ctx, id, qt)
+	}
+}
+
+func (r *Runner) limiterFor(provider clouds.Name) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.limiters[provider]
+	if !ok {
+		l = rate.NewLimiter(rate.Inf, 0)
+		r.limiters[provider] = l
+	}
+
+	return l
+}
+
+// run drains qt.Config.Node's node first, if this is a per-node task, then
+// executes qt's task with the bounded-retry backoff the request asked for,
+// and finally persists the terminal result so every replica's
+// AwaitCompletion callers - not just this one - observe it.
+func (r *Runner) run(ctx context.Context, id string, qt *queuedTask) {
+	defer func() {
+		r.mu.Lock()
+		r.inFlight[qt.Provider]--
+		r.mu.Unlock()
+	}()
+
+	// DrainNode is a prerequisite of every provider's DeleteNode workflow,
+	// not a workflow of its own - running it here, as the first thing the
+	// worker pool does with a per-node task, keeps it off the HTTP request
+	// path and under the same leader-election/rate-limiting as the
+	// deletion itself. It always proceeds to deletion on drain failure
+	// (logged, not fatal): the VM teardown removes the pods regardless, so
+	// refusing to delete over a stuck eviction would just strand the node.
+	if qt.Config.Node.Name != "" {
+		if err := steps.NewDrainNode().Run(ctx, qt.Config.Node.Name, qt.Config.DrainConfig); err != nil {
+			logrus.Warnf("workflows: drain node %s: %v, proceeding with deletion anyway", qt.Config.Node.Name, err)
+		}
+	}
+
+	const maxAttempts = 3
+
+	var runErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		writer, err := r.getWriter(id)
+		if err != nil {
+			runErr = errors.Wrap(err, "open task writer")
+			break
+		}
+
+		runErr = <-qt.Task.Run(ctx, qt.Config, writer)
+		writer.Close()
+
+		if runErr == nil {
+			break
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+
+	status := queueStatusDone
+	if runErr != nil {
+		status = queueStatusFailed
+		qt.Error = runErr.Error()
+		logrus.Errorf("workflows: task %s failed after retries: %v", id, runErr)
+	}
+
+	qt.Status = status
+	if data, err := json.Marshal(qt); err == nil {
+		if err := r.repo.Put(ctx, Prefix, id, data); err != nil {
+			logrus.Warnf("workflows: persist task %s status: %v", id, err)
+		}
+	}
+
+	Bus.Publish(qt.Config.ClusterName, TaskUpdate{TaskComplete: true})
+}
+
+// QueueStatus is the response body for GET /workflows/queue.
+type QueueStatus struct {
+	Depth    map[clouds.Name]int `json:"depth"`
+	InFlight map[clouds.Name]int `json:"inFlight"`
+}
+
+func (r *Runner) queueStatus(ctx context.Context) (QueueStatus, error) {
+	all, err := r.repo.GetAll(ctx, Prefix)
+	if err != nil {
+		return QueueStatus{}, errors.Wrap(err, "list queue")
+	}
+
+	out := QueueStatus{Depth: map[clouds.Name]int{}, InFlight: map[clouds.Name]int{}}
+
+	for _, raw := range all {
+		qt := &queuedTask{}
+		if err := json.Unmarshal(raw, qt); err != nil {
+			continue
+		}
+		if qt.Status == queueStatusPending {
+			out.Depth[qt.Provider]++
+		}
+	}
+
+	r.mu.Lock()
+	for name, n := range r.inFlight {
+		out.InFlight[name] = n
+	}
+	r.mu.Unlock()
+
+	return out, nil
+}
+
+// RegisterAdmin adds the GET /workflows/queue admin endpoint to r, exposing
+// queue depth and in-flight counts per provider.
+func (r *Runner) RegisterAdmin(router *mux.Router) {
+	router.HandleFunc("/workflows/queue", func(w http.ResponseWriter, req *http.Request) {
+		status, err := r.queueStatus(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}).Methods(http.MethodGet)
+}
@@ -0,0 +1,90 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/supergiant/supergiant/pkg/clouds"
+)
+
+// memStorage is a minimal in-memory storage.Interface fake for exercising
+// Runner's queue bookkeeping without a real etcd.
+type memStorage struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemStorage() *memStorage { return &memStorage{data: make(map[string][]byte)} }
+
+func (m *memStorage) Get(ctx context.Context, prefix, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[prefix+"/"+key], nil
+}
+
+func (m *memStorage) GetAll(ctx context.Context, prefix string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string][]byte)
+	for k, v := range m.data {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *memStorage) Put(ctx context.Context, prefix, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[prefix+"/"+key] = value
+	return nil
+}
+
+func (m *memStorage) Delete(ctx context.Context, prefix, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, prefix+"/"+key)
+	return nil
+}
+
+func (m *memStorage) GetWithRevision(ctx context.Context, prefix, key string) ([]byte, int64, error) {
+	v, err := m.Get(ctx, prefix, key)
+	return v, 0, err
+}
+
+func (m *memStorage) CompareAndSwap(ctx context.Context, prefix, key string, value []byte, resourceVersion int64) error {
+	return m.Put(ctx, prefix, key, value)
+}
+
+func TestRunner_QueueStatusCountsPendingByProvider(t *testing.T) {
+	repo := newMemStorage()
+	r := &Runner{repo: repo, inFlight: map[clouds.Name]int{}}
+
+	for i, provider := range []clouds.Name{clouds.DigitalOcean, clouds.DigitalOcean, clouds.AWS} {
+		qt := queuedTask{
+			Task:     &Task{ID: string(rune('a' + i))},
+			Provider: provider,
+			Status:   queueStatusPending,
+		}
+		data, err := json.Marshal(qt)
+		if err != nil {
+			t.Fatalf("marshal queuedTask: %v", err)
+		}
+		if err := repo.Put(context.Background(), Prefix, qt.Task.ID, data); err != nil {
+			t.Fatalf("put queuedTask: %v", err)
+		}
+	}
+
+	status, err := r.queueStatus(context.Background())
+	if err != nil {
+		t.Fatalf("queueStatus: %v", err)
+	}
+
+	if status.Depth[clouds.DigitalOcean] != 2 {
+		t.Errorf("expected 2 pending DigitalOcean tasks, got %d", status.Depth[clouds.DigitalOcean])
+	}
+	if status.Depth[clouds.AWS] != 1 {
+		t.Errorf("expected 1 pending AWS task, got %d", status.Depth[clouds.AWS])
+	}
+}
@@ -0,0 +1,178 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// mirrorPodAnnotation marks a pod as managed directly by the kubelet (e.g. a
+// static pod), which can't be evicted and goes away with the node anyway.
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// DrainConfig controls how DrainNode empties a node before the provider's
+// DeleteNode workflow tears down the underlying VM. Kubeconfig is the
+// cluster's admin credentials, as read via Handler.svc.GetCerts.
+type DrainConfig struct {
+	Force       bool
+	GracePeriod time.Duration
+	Kubeconfig  []byte
+}
+
+// DrainNode cordons a node and evicts its pods before it's deleted, so
+// workloads get a chance to reschedule elsewhere instead of being ripped out
+// with the VM. It's a prerequisite step for every provider's DeleteNode
+// workflow, not provider-specific itself.
+type DrainNode struct{}
+
+// NewDrainNode returns a DrainNode step.
+func NewDrainNode() *DrainNode {
+	return &DrainNode{}
+}
+
+func (*DrainNode) Name() string {
+	return "drainNode"
+}
+
+func (*DrainNode) Description() string {
+	return "cordon and evict pods from a node before it is deleted"
+}
+
+// Run cordons nodeName and waits for its evictable pods to clear out. If
+// cfg.Force is set it only cordons - skipping eviction - so the caller can
+// proceed immediately even if pods won't drain in time.
+func (d *DrainNode) Run(ctx context.Context, nodeName string, cfg DrainConfig) error {
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(cfg.Kubeconfig)
+	if err != nil {
+		return errors.Wrap(err, "build rest config from kubeconfig")
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "build kube client")
+	}
+
+	if err := cordon(ctx, clientset, nodeName); err != nil {
+		return errors.Wrap(err, "cordon node")
+	}
+
+	if cfg.Force {
+		return nil
+	}
+
+	gracePeriod := cfg.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = 30 * time.Second
+	}
+
+	pods, err := evictablePods(ctx, clientset, nodeName)
+	if err != nil {
+		return errors.Wrap(err, "list evictable pods")
+	}
+
+	for _, pod := range pods {
+		if err := evict(ctx, clientset, pod); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "evict pod %s/%s", pod.Namespace, pod.Name)
+		}
+	}
+
+	return waitForDrained(ctx, clientset, nodeName, gracePeriod)
+}
+
+func cordon(ctx context.Context, clientset kubernetes.Interface, nodeName string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"unschedulable": true},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal cordon patch")
+	}
+
+	_, err = clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// evictablePods lists the pods on nodeName that aren't owned by a DaemonSet
+// and aren't a static/mirror pod - both of which stay on the node (or come
+// back immediately) regardless of eviction, so draining them is pointless.
+func evictablePods(ctx context.Context, clientset kubernetes.Interface, nodeName string) ([]corev1.Pod, error) {
+	list, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	for _, pod := range list.Items {
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+
+		if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+			continue
+		}
+
+		ownedByDaemonSet := false
+		for _, ref := range pod.OwnerReferences {
+			if ref.Kind == "DaemonSet" {
+				ownedByDaemonSet = true
+				break
+			}
+		}
+		if ownedByDaemonSet {
+			continue
+		}
+
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
+
+// evict requests a graceful eviction through the policy/v1beta1 Eviction
+// subresource, which the API server rejects if it would violate the pod's
+// PodDisruptionBudget.
+func evict(ctx context.Context, clientset kubernetes.Interface, pod corev1.Pod) error {
+	return clientset.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, &policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	})
+}
+
+// waitForDrained polls until nodeName has no evictable pods left or timeout
+// elapses, at which point the caller proceeds with deletion anyway.
+func waitForDrained(ctx context.Context, clientset kubernetes.Interface, nodeName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		pods, err := evictablePods(ctx, clientset, nodeName)
+		if err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
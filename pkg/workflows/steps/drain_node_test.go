@@ -0,0 +1,50 @@
+package steps
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEvictablePods_SkipsDaemonSetAndMirrorPods(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kube-proxy",
+				Namespace: "kube-system",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "DaemonSet", Name: "kube-proxy"},
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-1"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "kube-apiserver-node-1",
+				Namespace:   "kube-system",
+				Annotations: map[string]string{mirrorPodAnnotation: "true"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-1"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-node-app", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node-2"},
+		},
+	)
+
+	pods, err := evictablePods(context.Background(), clientset, "node-1")
+	if err != nil {
+		t.Fatalf("evictablePods: %v", err)
+	}
+
+	if len(pods) != 1 || pods[0].Name != "app" {
+		t.Fatalf("expected only the non-DaemonSet, non-mirror pod on node-1, got %+v", pods)
+	}
+}